@@ -0,0 +1,48 @@
+package gogit
+
+import "context"
+
+// Auth carries credentials for authenticating against a remote. Set it
+// via GitRepo.SetSSHAuth or GitRepo.SetBasicAuth; the two are mutually
+// exclusive, and the latest call wins.
+type Auth struct {
+	sshKeyPath    string
+	sshPassphrase string
+	basicUsername string
+	basicToken    string
+}
+
+// SetSSHAuth configures gr to authenticate against SSH remotes using the
+// private key at privateKeyPath, decrypting it with passphrase if it is
+// encrypted (pass "" for an unencrypted key). The passphrase is used only
+// by the default go-git backend; binaryGitter passes just the key path to
+// ssh via GIT_SSH_COMMAND.
+func (gr *GitRepo) SetSSHAuth(privateKeyPath, passphrase string) {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+	gr.auth = &Auth{sshKeyPath: privateKeyPath, sshPassphrase: passphrase}
+}
+
+// SetBasicAuth configures gr to authenticate against HTTPS remotes using
+// username and a personal access token.
+func (gr *GitRepo) SetBasicAuth(username, token string) {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+	gr.auth = &Auth{basicUsername: username, basicToken: token}
+}
+
+type authCtxKeyType struct{}
+
+var authCtxKey authCtxKeyType
+
+func withAuth(ctx context.Context, auth *Auth) context.Context {
+	if auth == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, authCtxKey, auth)
+}
+
+func authFromContext(ctx context.Context) *Auth {
+	auth, _ := ctx.Value(authCtxKey).(*Auth)
+	return auth
+}
@@ -0,0 +1,61 @@
+package gogit
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestCredentialConfigFileNoShellInjection verifies that a token
+// containing shell metacharacters (quotes, backticks, semicolons) ends up
+// safely base64-encoded inside a 0600 gitconfig file, rather than being
+// spliced into a shell command string.
+func TestCredentialConfigFileNoShellInjection(t *testing.T) {
+	const user = "user"
+	const token = `tok'en"with` + "`backtick`" + `;rm -rf /`
+	ctx := withAuth(context.Background(), &Auth{basicUsername: user, basicToken: token})
+
+	path, cleanup, err := credentialConfigFile(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	if path == "" {
+		t.Fatal("expected a credential config file path, got none")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("credential file mode = %o, want 0600", perm)
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantToken := base64.StdEncoding.EncodeToString([]byte(user + ":" + token))
+	if !strings.Contains(string(contents), wantToken) {
+		t.Errorf("credential file %q does not contain expected base64 token %q", contents, wantToken)
+	}
+	if strings.Contains(string(contents), token) {
+		t.Errorf("credential file %q contains the raw token, want only the base64 form", contents)
+	}
+}
+
+// TestCredentialConfigFileNoAuth verifies that no file is created when no
+// basic auth was configured.
+func TestCredentialConfigFileNoAuth(t *testing.T) {
+	path, cleanup, err := credentialConfigFile(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	if path != "" {
+		t.Errorf("expected no credential config file, got %q", path)
+	}
+}
@@ -0,0 +1,76 @@
+package gogit
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+)
+
+// BlobStorage is a pluggable backend for storing large binary payloads
+// outside of the git repository itself, git-LFS style. See
+// GitRepo.SetBlobStorage. Implementations live under gogit/blob/s3 and
+// gogit/blob/gcs.
+type BlobStorage interface {
+	Write(key string, data []byte) error
+	Read(key string) ([]byte, error)
+}
+
+// blobPointerMagic marks a file committed to git as a pointer to data
+// held in blob storage, rather than the data itself.
+const blobPointerMagic = "gogit-blob-pointer-v1\n"
+
+type blobPointer struct {
+	Key    string `json:"key"`
+	Size   int    `json:"size"`
+	Sha256 string `json:"sha256"`
+}
+
+// SetBlobStorage configures bs as the destination for payloads passed to
+// AddData/AddDataWithMessage that exceed threshold bytes. Oversized
+// payloads are uploaded to bs under a content-hash key; only a small
+// pointer file is committed to git. Pass threshold <= 0 to disable.
+func (gr *GitRepo) SetBlobStorage(bs BlobStorage, threshold int) {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+	gr.blobStorage = bs
+	gr.blobThreshold = threshold
+}
+
+// GetData reads the file at fp (relative to the repo root), transparently
+// resolving it if it is a blob storage pointer written by AddData.
+func (gr *GitRepo) GetData(fp string) ([]byte, error) {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+	raw, err := ioutil.ReadFile(filepath.Join(gr.folder, fp))
+	if err != nil {
+		return nil, err
+	}
+	if gr.blobStorage == nil || !bytes.HasPrefix(raw, []byte(blobPointerMagic)) {
+		return raw, nil
+	}
+	var ptr blobPointer
+	if err := json.Unmarshal(raw[len(blobPointerMagic):], &ptr); err != nil {
+		return nil, err
+	}
+	gr.log.Infof("resolving blob pointer %s (%d bytes)", ptr.Key, ptr.Size)
+	return gr.blobStorage.Read(ptr.Key)
+}
+
+// externalizeBlob uploads data to gr.blobStorage under a sha256 content
+// key and returns the pointer file contents to commit in its place.
+func (gr *GitRepo) externalizeBlob(data []byte) ([]byte, error) {
+	sum := sha256.Sum256(data)
+	key := hex.EncodeToString(sum[:])
+	gr.log.Infof("externalizing %d bytes to blob storage under %s", len(data), key)
+	if err := gr.blobStorage.Write(key, data); err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(blobPointer{Key: key, Size: len(data), Sha256: key})
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(blobPointerMagic), body...), nil
+}
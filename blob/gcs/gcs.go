@@ -0,0 +1,58 @@
+// Package gcs implements gogit.BlobStorage backed by a Google Cloud
+// Storage bucket, for externalizing large payloads passed to
+// GitRepo.AddData.
+package gcs
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+
+	"cloud.google.com/go/storage"
+)
+
+// Storage implements gogit.BlobStorage backed by a GCS bucket.
+type Storage struct {
+	bucket *storage.BucketHandle
+	prefix string
+}
+
+// New creates a GCS-backed BlobStorage for bucketName, using application
+// default credentials. prefix is prepended to every object name (e.g.
+// "gogit-blobs/"); pass "" for none.
+func New(bucketName, prefix string) (*Storage, error) {
+	client, err := storage.NewClient(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("creating GCS client: %w", err)
+	}
+	return &Storage{
+		bucket: client.Bucket(bucketName),
+		prefix: prefix,
+	}, nil
+}
+
+func (s *Storage) objectName(key string) string {
+	return s.prefix + key
+}
+
+// Write uploads data to the bucket under key.
+func (s *Storage) Write(key string, data []byte) error {
+	ctx := context.Background()
+	w := s.bucket.Object(s.objectName(key)).NewWriter(ctx)
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// Read downloads the object stored under key.
+func (s *Storage) Read(key string) ([]byte, error) {
+	ctx := context.Background()
+	r, err := s.bucket.Object(s.objectName(key)).NewReader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("opening gcs object %s: %w", key, err)
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
@@ -0,0 +1,66 @@
+// Package s3 implements gogit.BlobStorage backed by an S3 bucket, for
+// externalizing large payloads passed to GitRepo.AddData.
+package s3
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Storage implements gogit.BlobStorage backed by an S3 bucket.
+type Storage struct {
+	bucket string
+	prefix string
+	client *s3.Client
+}
+
+// New creates an S3-backed BlobStorage for bucket, loading credentials
+// and region from the default AWS config chain. prefix is prepended to
+// every object key (e.g. "gogit-blobs/"); pass "" for none.
+func New(bucket, prefix string) (*Storage, error) {
+	cfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("loading AWS config: %w", err)
+	}
+	return &Storage{
+		bucket: bucket,
+		prefix: prefix,
+		client: s3.NewFromConfig(cfg),
+	}, nil
+}
+
+func (s *Storage) objectKey(key string) string {
+	return s.prefix + key
+}
+
+// Write uploads data to the bucket under key.
+func (s *Storage) Write(key string, data []byte) error {
+	_, err := s.client.PutObject(context.Background(), &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.objectKey(key)),
+		Body:   bytes.NewReader(data),
+	})
+	return err
+}
+
+// Read downloads the object stored under key.
+func (s *Storage) Read(key string) ([]byte, error) {
+	out, err := s.client.GetObject(context.Background(), &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.objectKey(key)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getting s3 object %s: %w", key, err)
+	}
+	defer out.Body.Close()
+	buf := new(bytes.Buffer)
+	if _, err := buf.ReadFrom(out.Body); err != nil {
+		return nil, fmt.Errorf("reading s3 object %s: %w", key, err)
+	}
+	return buf.Bytes(), nil
+}
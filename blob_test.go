@@ -0,0 +1,97 @@
+package gogit
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	git "github.com/go-git/go-git/v5"
+)
+
+// fakeBlobStorage is an in-memory BlobStorage for tests.
+type fakeBlobStorage struct {
+	objects map[string][]byte
+}
+
+func newFakeBlobStorage() *fakeBlobStorage {
+	return &fakeBlobStorage{objects: make(map[string][]byte)}
+}
+
+func (f *fakeBlobStorage) Write(key string, data []byte) error {
+	f.objects[key] = append([]byte(nil), data...)
+	return nil
+}
+
+func (f *fakeBlobStorage) Read(key string) ([]byte, error) {
+	data, ok := f.objects[key]
+	if !ok {
+		return nil, errors.New("no such key: " + key)
+	}
+	return data, nil
+}
+
+// TestAddDataBlobRoundTrip verifies that a payload over the blob threshold
+// is externalized to BlobStorage and committed as a pointer file, and that
+// GetData transparently resolves the pointer back to the original data.
+func TestAddDataBlobRoundTrip(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "gogit-blob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	folder := filepath.Join(tmp, "repo")
+	if _, err := git.PlainInit(folder, false); err != nil {
+		t.Fatal(err)
+	}
+	gr, err := New("file://"+folder, folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gr.SetIdentity("Test User", "test@example.com")
+
+	store := newFakeBlobStorage()
+	gr.SetBlobStorage(store, 4)
+
+	large := []byte("this payload is well over the threshold")
+	if err := gr.AddData(large, "large.bin"); err != nil {
+		t.Fatal(err)
+	}
+
+	onDisk, err := ioutil.ReadFile(filepath.Join(folder, "large.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(onDisk, []byte(blobPointerMagic)) {
+		t.Fatalf("committed file is not a blob pointer: %q", onDisk)
+	}
+	if len(store.objects) != 1 {
+		t.Fatalf("got %d objects in blob storage, want 1", len(store.objects))
+	}
+
+	got, err := gr.GetData("large.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, large) {
+		t.Errorf("GetData = %q, want %q", got, large)
+	}
+
+	small := []byte("tiny")
+	if err := gr.AddData(small, "small.bin"); err != nil {
+		t.Fatal(err)
+	}
+	onDisk, err = ioutil.ReadFile(filepath.Join(folder, "small.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(onDisk, small) {
+		t.Errorf("small.bin on disk = %q, want raw %q (should not be externalized)", onDisk, small)
+	}
+	if len(store.objects) != 1 {
+		t.Errorf("got %d objects in blob storage, want still 1 after small payload", len(store.objects))
+	}
+}
@@ -0,0 +1,13 @@
+package gogit
+
+import "time"
+
+// CommitSummary describes a single commit, as returned by GitRepo.Log.
+type CommitSummary struct {
+	Sha         string
+	ShortSha    string
+	AuthorName  string
+	AuthorEmail string
+	CommitDate  time.Time
+	Subject     string
+}
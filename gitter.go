@@ -0,0 +1,343 @@
+package gogit
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Gitter abstracts the git plumbing used by GitRepo, so the implementation
+// backing clone/pull/add/commit/push can be swapped between the pure-Go
+// go-git library and the system `git` binary. Every method takes a
+// context so long-running network operations (clone, pull, push) can be
+// cancelled by GitRepo.WithContext or process.Manager.Kill.
+type Gitter interface {
+	// Clone clones repo into folder. branch may be empty to accept the
+	// remote's default branch, in which case resolvedBranch reports the
+	// branch that was actually checked out (e.g. "main"), so the caller
+	// can remember it for subsequent Pull/Push calls.
+	Clone(ctx context.Context, repo, folder, branch string) (resolvedBranch string, err error)
+	// Pull fetches remote/branch and brings folder's checkout up to date
+	// with it. binaryGitter rebases local commits onto it; libGitter only
+	// fast-forwards and returns an error if the histories have diverged.
+	Pull(ctx context.Context, folder, remote, branch string) error
+	// Add stages fp (relative to folder) for the next commit.
+	Add(ctx context.Context, folder, fp string) error
+	// Commit commits the repo's staged changes with message, using
+	// identity as the author/committer if non-nil, and returns the
+	// resulting commit SHA.
+	Commit(ctx context.Context, folder, message string, identity *Identity) (string, error)
+	// Push pushes folder's branch to remote.
+	Push(ctx context.Context, folder, remote, branch string) error
+	// Checkout switches folder's working tree to branchOrCommit, which
+	// may be either a branch name or a 40-character hex commit SHA.
+	Checkout(ctx context.Context, folder, branchOrCommit string) error
+	// Log returns the n most recent commits reachable from HEAD, newest
+	// first. n <= 0 means no limit.
+	Log(ctx context.Context, folder string, n int) ([]CommitSummary, error)
+}
+
+// Identity is the author/committer identity to use for a commit, since
+// AddData/AddDataWithMessage cannot rely on ambient `git config
+// user.name`/`user.email` being set.
+type Identity struct {
+	Name  string
+	Email string
+}
+
+// isCommitSHA reports whether s looks like a full 40-character hex git
+// commit SHA, as opposed to a branch name.
+func isCommitSHA(s string) bool {
+	if len(s) != 40 {
+		return false
+	}
+	for _, c := range s {
+		if !((c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')) {
+			return false
+		}
+	}
+	return true
+}
+
+// binaryGitter implements Gitter by shelling out to the system `git`
+// binary. It is kept around for users who rely on ambient git config,
+// credential helpers, or hooks already set up on the host.
+type binaryGitter struct {
+	log *logrus.Entry
+}
+
+// runContext starts name/args with its working directory set to folder
+// and waits for it in a goroutine, so that if ctx is cancelled mid-run
+// (e.g. a stalled network clone) the process is killed rather than
+// leaking a hung command. It never touches the process-global working
+// directory (no os.Chdir), so concurrent calls against different folders
+// cannot race with each other.
+func runContext(ctx context.Context, folder, name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = folder
+	if env := contextEnv(ctx); env != nil {
+		cmd.Env = env
+	}
+	var buf bytes.Buffer
+	cmd.Stdout = &buf
+	cmd.Stderr = &buf
+	if err := cmd.Start(); err != nil {
+		return buf.Bytes(), err
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case <-ctx.Done():
+		_ = cmd.Process.Kill()
+		<-done
+		return buf.Bytes(), ctx.Err()
+	case err := <-done:
+		return buf.Bytes(), err
+	}
+}
+
+// identityEnvKey carries a *Identity through context so runContext can
+// set GIT_AUTHOR_*/GIT_COMMITTER_* without widening every call site's
+// signature.
+type identityEnvKeyType struct{}
+
+var identityEnvKey identityEnvKeyType
+
+func withIdentity(ctx context.Context, identity *Identity) context.Context {
+	if identity == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, identityEnvKey, identity)
+}
+
+// extraEnvKeyType carries ad-hoc "KEY=VALUE" environment entries (e.g. the
+// GIT_CONFIG_GLOBAL set by credentialConfigFile) through context, so a
+// single git subprocess call can extend its environment without widening
+// every call site's signature.
+type extraEnvKeyType struct{}
+
+var extraEnvKey extraEnvKeyType
+
+func withExtraEnv(ctx context.Context, entry string) context.Context {
+	return context.WithValue(ctx, extraEnvKey, entry)
+}
+
+// contextEnv builds the environment for a git subprocess, layering on
+// GIT_AUTHOR_*/GIT_COMMITTER_* (from an Identity set via withIdentity),
+// GIT_SSH_COMMAND (from an Auth set via GitRepo.SetSSHAuth), and any entry
+// set via withExtraEnv, on top of the ambient environment. It returns nil
+// if none of these were set, so the caller can leave cmd.Env unset
+// (inheriting the ambient environment).
+func contextEnv(ctx context.Context) []string {
+	var extra []string
+	if identity, ok := ctx.Value(identityEnvKey).(*Identity); ok {
+		extra = append(extra,
+			"GIT_AUTHOR_NAME="+identity.Name,
+			"GIT_AUTHOR_EMAIL="+identity.Email,
+			"GIT_COMMITTER_NAME="+identity.Name,
+			"GIT_COMMITTER_EMAIL="+identity.Email,
+		)
+	}
+	if auth := authFromContext(ctx); auth != nil && auth.sshKeyPath != "" {
+		extra = append(extra, "GIT_SSH_COMMAND=ssh -i "+auth.sshKeyPath+" -o IdentitiesOnly=yes")
+	}
+	if entry, ok := ctx.Value(extraEnvKey).(string); ok {
+		extra = append(extra, entry)
+	}
+	if len(extra) == 0 {
+		return nil
+	}
+	return append(os.Environ(), extra...)
+}
+
+// credentialConfigFile writes a short-lived, 0600 gitconfig file that
+// supplies an HTTP Basic Authorization header for an Auth set via
+// GitRepo.SetBasicAuth, so the username/token never appear in a shell
+// command string (command injection) or in the subprocess's argv (visible
+// to any local user via `ps aux`). The caller must invoke the returned
+// cleanup func (typically via defer) once the git command has finished.
+// It returns an empty path and a no-op cleanup if no basic auth was set.
+func credentialConfigFile(ctx context.Context) (path string, cleanup func(), err error) {
+	auth := authFromContext(ctx)
+	if auth == nil || auth.basicUsername == "" {
+		return "", func() {}, nil
+	}
+	f, err := os.CreateTemp("", "gogit-credentials-*.gitconfig")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup = func() { os.Remove(f.Name()) }
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		cleanup()
+		return "", nil, err
+	}
+	token := base64.StdEncoding.EncodeToString([]byte(auth.basicUsername + ":" + auth.basicToken))
+	_, writeErr := f.WriteString("[http]\n\textraHeader = Authorization: Basic " + token + "\n")
+	closeErr := f.Close()
+	if writeErr != nil {
+		cleanup()
+		return "", nil, writeErr
+	}
+	if closeErr != nil {
+		cleanup()
+		return "", nil, closeErr
+	}
+	return f.Name(), cleanup, nil
+}
+
+// authEnv returns the environment variable that points git at the
+// credential config file built by credentialConfigFile, so it applies only
+// to this subprocess rather than the user's ambient gitconfig.
+func authEnv(configPath string) string {
+	return "GIT_CONFIG_GLOBAL=" + configPath
+}
+
+func (g *binaryGitter) Clone(ctx context.Context, repo, folder, branch string) (string, error) {
+	credPath, cleanup, err := credentialConfigFile(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+	args := []string{"clone"}
+	if branch != "" {
+		args = append(args, "-b", branch)
+	}
+	args = append(args, repo, ".")
+	if credPath != "" {
+		ctx = withExtraEnv(ctx, authEnv(credPath))
+	}
+	g.log.Infof("Running: git %v", args)
+	stdoutStderr, err := runContext(ctx, folder, "git", args...)
+	g.log.Infof("Output: [%s]\n", stdoutStderr)
+	if bytes.Contains(stdoutStderr, []byte("fatal")) {
+		return "", errors.New("Could not clone repo")
+	}
+	if err != nil {
+		return "", err
+	}
+	if branch != "" {
+		return branch, nil
+	}
+	head, err := runContext(ctx, folder, "git", "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(head)), nil
+}
+
+func (g *binaryGitter) Pull(ctx context.Context, folder, remote, branch string) error {
+	credPath, cleanup, err := credentialConfigFile(ctx)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	if credPath != "" {
+		ctx = withExtraEnv(ctx, authEnv(credPath))
+	}
+	args := []string{"pull", "--rebase", remote, branch}
+	g.log.Infof("Running: git pull --rebase %s %s", remote, branch)
+	stdoutStderr, err := runContext(ctx, folder, "git", args...)
+	g.log.Infof("Output: [%s]\n", stdoutStderr)
+	if bytes.Contains(stdoutStderr, []byte("fatal")) {
+		return errors.New("Could not pull repo")
+	}
+	return err
+}
+
+func (g *binaryGitter) Add(ctx context.Context, folder, fp string) error {
+	g.log.Info("git add ", fp)
+	stdoutStderr, err := runContext(ctx, folder, "git", "add", fp)
+	g.log.Infof("Output: [%s]\n", stdoutStderr)
+	if bytes.Contains(stdoutStderr, []byte("error")) {
+		return errors.New(string(stdoutStderr))
+	}
+	return err
+}
+
+func (g *binaryGitter) Commit(ctx context.Context, folder, message string, identity *Identity) (sha string, err error) {
+	ctx = withIdentity(ctx, identity)
+	g.log.Info("git commit -m ", message)
+	stdoutStderr, err := runContext(ctx, folder, "git", "commit", "-m", message)
+	g.log.Infof("Output: [%s]\n", stdoutStderr)
+	if bytes.Contains(stdoutStderr, []byte("error")) {
+		return "", errors.New(string(stdoutStderr))
+	}
+	if err != nil {
+		return "", err
+	}
+	stdoutStderr, err = runContext(ctx, folder, "git", "rev-parse", "HEAD")
+	return strings.TrimSpace(string(stdoutStderr)), err
+}
+
+const logFieldSep = "\x1f"
+const logRecordSep = "\x1e"
+
+func (g *binaryGitter) Log(ctx context.Context, folder string, n int) (summaries []CommitSummary, err error) {
+	format := strings.Join([]string{"%H", "%h", "%an", "%ae", "%cI", "%s"}, logFieldSep) + logRecordSep
+	args := []string{"log", "--pretty=format:" + format}
+	if n > 0 {
+		args = append(args, "-n", strconv.Itoa(n))
+	}
+	stdoutStderr, err := runContext(ctx, folder, "git", args...)
+	if err != nil {
+		return nil, errors.New(string(stdoutStderr))
+	}
+	for _, record := range strings.Split(string(stdoutStderr), logRecordSep) {
+		record = strings.TrimSpace(record)
+		if record == "" {
+			continue
+		}
+		fields := strings.Split(record, logFieldSep)
+		if len(fields) != 6 {
+			continue
+		}
+		commitDate, _ := time.Parse(time.RFC3339, fields[4])
+		summaries = append(summaries, CommitSummary{
+			Sha:         fields[0],
+			ShortSha:    fields[1],
+			AuthorName:  fields[2],
+			AuthorEmail: fields[3],
+			CommitDate:  commitDate,
+			Subject:     fields[5],
+		})
+	}
+	return summaries, nil
+}
+
+func (g *binaryGitter) Push(ctx context.Context, folder, remote, branch string) error {
+	credPath, cleanup, err := credentialConfigFile(ctx)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	if credPath != "" {
+		ctx = withExtraEnv(ctx, authEnv(credPath))
+	}
+	args := []string{"push", remote, branch}
+	g.log.Infof("git push %s %s", remote, branch)
+	stdoutStderr, err := runContext(ctx, folder, "git", args...)
+	g.log.Infof("Output: [%s]\n", stdoutStderr)
+	if bytes.Contains(stdoutStderr, []byte("error")) {
+		return errors.New(string(stdoutStderr))
+	}
+	return err
+}
+
+func (g *binaryGitter) Checkout(ctx context.Context, folder, branchOrCommit string) error {
+	g.log.Info("git checkout ", branchOrCommit)
+	stdoutStderr, err := runContext(ctx, folder, "git", "checkout", branchOrCommit)
+	g.log.Infof("Output: [%s]\n", stdoutStderr)
+	if bytes.Contains(stdoutStderr, []byte("error")) {
+		return errors.New(string(stdoutStderr))
+	}
+	return err
+}
@@ -0,0 +1,208 @@
+package gogit
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/sirupsen/logrus"
+)
+
+// authMethod builds the go-git transport.AuthMethod for an Auth set via
+// GitRepo.SetSSHAuth/SetBasicAuth, or nil if none was set.
+func authMethod(ctx context.Context) (transport.AuthMethod, error) {
+	auth := authFromContext(ctx)
+	if auth == nil {
+		return nil, nil
+	}
+	if auth.sshKeyPath != "" {
+		return gitssh.NewPublicKeysFromFile("git", auth.sshKeyPath, auth.sshPassphrase)
+	}
+	if auth.basicUsername != "" {
+		return &githttp.BasicAuth{Username: auth.basicUsername, Password: auth.basicToken}, nil
+	}
+	return nil, nil
+}
+
+// libGitter implements Gitter using the pure-Go go-git library, so gogit
+// does not require a `git` binary on PATH. This is the default backend
+// returned by New.
+type libGitter struct {
+	log *logrus.Entry
+}
+
+func (g *libGitter) Clone(ctx context.Context, repo, folder, branch string) (string, error) {
+	opts := &git.CloneOptions{URL: repo}
+	if branch != "" {
+		opts.ReferenceName = plumbing.NewBranchReferenceName(branch)
+	}
+	auth, err := authMethod(ctx)
+	if err != nil {
+		return "", err
+	}
+	opts.Auth = auth
+	g.log.Infof("go-git: cloning %s into %s", repo, folder)
+	r, err := git.PlainCloneContext(ctx, folder, false, opts)
+	if err != nil {
+		return "", err
+	}
+	if branch != "" {
+		return branch, nil
+	}
+	head, err := r.Head()
+	if err != nil {
+		return "", err
+	}
+	return head.Name().Short(), nil
+}
+
+func (g *libGitter) Pull(ctx context.Context, folder, remote, branch string) error {
+	r, err := git.PlainOpen(folder)
+	if err != nil {
+		return err
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		return err
+	}
+	g.log.Infof("go-git: pulling %s %s", remote, branch)
+	opts := &git.PullOptions{RemoteName: remote}
+	if branch != "" {
+		opts.ReferenceName = plumbing.NewBranchReferenceName(branch)
+	}
+	auth, err := authMethod(ctx)
+	if err != nil {
+		return err
+	}
+	opts.Auth = auth
+	err = w.PullContext(ctx, opts)
+	if err == git.NoErrAlreadyUpToDate {
+		return nil
+	}
+	return err
+}
+
+func (g *libGitter) Add(ctx context.Context, folder, fp string) error {
+	r, err := git.PlainOpen(folder)
+	if err != nil {
+		return err
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		return err
+	}
+	g.log.Infof("go-git: adding %s", fp)
+	_, err = w.Add(fp)
+	return err
+}
+
+func (g *libGitter) Commit(ctx context.Context, folder, message string, identity *Identity) (string, error) {
+	r, err := git.PlainOpen(folder)
+	if err != nil {
+		return "", err
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		return "", err
+	}
+	g.log.Infof("go-git: committing %q", message)
+	opts := &git.CommitOptions{}
+	if identity != nil {
+		sig := &object.Signature{
+			Name:  identity.Name,
+			Email: identity.Email,
+			When:  time.Now(),
+		}
+		opts.Author = sig
+		opts.Committer = sig
+	}
+	hash, err := w.Commit(message, opts)
+	if err != nil {
+		return "", err
+	}
+	return hash.String(), nil
+}
+
+func (g *libGitter) Log(ctx context.Context, folder string, n int) ([]CommitSummary, error) {
+	r, err := git.PlainOpen(folder)
+	if err != nil {
+		return nil, err
+	}
+	cIter, err := r.Log(&git.LogOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer cIter.Close()
+
+	var summaries []CommitSummary
+	err = cIter.ForEach(func(c *object.Commit) error {
+		if n > 0 && len(summaries) >= n {
+			return storer.ErrStop
+		}
+		summaries = append(summaries, CommitSummary{
+			Sha:         c.Hash.String(),
+			ShortSha:    c.Hash.String()[:7],
+			AuthorName:  c.Author.Name,
+			AuthorEmail: c.Author.Email,
+			CommitDate:  c.Committer.When,
+			Subject:     strings.SplitN(c.Message, "\n", 2)[0],
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return summaries, nil
+}
+
+func (g *libGitter) Push(ctx context.Context, folder, remote, branch string) error {
+	r, err := git.PlainOpen(folder)
+	if err != nil {
+		return err
+	}
+	g.log.Infof("go-git: pushing %s %s", remote, branch)
+	opts := &git.PushOptions{RemoteName: remote}
+	if branch != "" {
+		refSpec := config.RefSpec("refs/heads/" + branch + ":refs/heads/" + branch)
+		opts.RefSpecs = []config.RefSpec{refSpec}
+	}
+	auth, err := authMethod(ctx)
+	if err != nil {
+		return err
+	}
+	opts.Auth = auth
+	err = r.PushContext(ctx, opts)
+	if err == git.NoErrAlreadyUpToDate {
+		return nil
+	}
+	return err
+}
+
+// Checkout switches folder's working tree to branchOrCommit, detecting
+// whether it's a 40-character hex commit SHA or a branch name.
+func (g *libGitter) Checkout(ctx context.Context, folder, branchOrCommit string) error {
+	r, err := git.PlainOpen(folder)
+	if err != nil {
+		return err
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		return err
+	}
+	opts := &git.CheckoutOptions{}
+	if isCommitSHA(branchOrCommit) {
+		opts.Hash = plumbing.NewHash(branchOrCommit)
+	} else {
+		opts.Branch = plumbing.NewBranchReferenceName(branchOrCommit)
+	}
+	g.log.Infof("go-git: checking out %s", branchOrCommit)
+	return w.Checkout(opts)
+}
@@ -0,0 +1,131 @@
+package gogit
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// TestBinaryGitterRoundTrip exercises the binaryGitter backend (clone,
+// add, commit, push, log, checkout) against a real `git` binary.
+func TestBinaryGitterRoundTrip(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git binary not found on PATH")
+	}
+
+	tmp, err := ioutil.TempDir("", "gogit-binary-gitter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	remoteFolder := filepath.Join(tmp, "remote.git")
+	if _, err := git.PlainInit(remoteFolder, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// git can't clone an empty remote, so seed it with an initial commit
+	// via go-git before handing the clone off to binaryGitter.
+	seedFolder := filepath.Join(tmp, "seed")
+	seed, err := git.PlainInit(seedFolder, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := seed.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{remoteFolder}}); err != nil {
+		t.Fatal(err)
+	}
+	seedWorktree, err := seed.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(seedFolder, "seed.txt"), []byte("seed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := seedWorktree.Add("seed.txt"); err != nil {
+		t.Fatal(err)
+	}
+	seedSig := &object.Signature{Name: "Test", Email: "test@example.com"}
+	seedCommit, err := seedWorktree.Commit("seed commit", &git.CommitOptions{Author: seedSig, Committer: seedSig})
+	if err != nil {
+		t.Fatal(err)
+	}
+	seedHead, err := seed.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := seed.Push(&git.PushOptions{RemoteName: "origin"}); err != nil {
+		t.Fatal(err)
+	}
+	branch := seedHead.Name().Short()
+
+	cloneFolder := filepath.Join(tmp, "clone")
+	gr, err := New(remoteFolder, cloneFolder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gr.SetGitter(&binaryGitter{log: gr.log})
+	gr.SetBranch(branch)
+	gr.SetIdentity("Test", "test@example.com")
+
+	if err := gr.Update(); err != nil {
+		t.Fatalf("Update (clone): %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(cloneFolder, "seed.txt")); err != nil {
+		t.Fatalf("seed.txt missing after clone: %v", err)
+	}
+
+	newCommit, err := gr.AddDataWithMessage([]byte("hello"), "hello.txt", "add hello.txt")
+	if err != nil {
+		t.Fatalf("AddDataWithMessage: %v", err)
+	}
+
+	if err := gr.Push(); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+
+	summaries, err := gr.Log(0)
+	if err != nil {
+		t.Fatalf("Log: %v", err)
+	}
+	if len(summaries) != 2 {
+		t.Fatalf("got %d log entries, want 2", len(summaries))
+	}
+	if summaries[0].Sha != newCommit || summaries[0].Subject != "add hello.txt" {
+		t.Errorf("summaries[0] = %+v, want sha %s subject %q", summaries[0], newCommit, "add hello.txt")
+	}
+	if summaries[1].Sha != seedCommit.String() || summaries[1].Subject != "seed commit" {
+		t.Errorf("summaries[1] = %+v, want sha %s subject %q", summaries[1], seedCommit.String(), "seed commit")
+	}
+
+	if err := gr.Checkout(seedCommit.String()); err != nil {
+		t.Fatalf("Checkout(seed commit): %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(cloneFolder, "hello.txt")); !os.IsNotExist(err) {
+		t.Errorf("hello.txt should not exist after checking out the seed commit, stat err = %v", err)
+	}
+
+	if err := gr.Checkout(branch); err != nil {
+		t.Fatalf("Checkout(%s): %v", branch, err)
+	}
+	if _, err := os.Stat(filepath.Join(cloneFolder, "hello.txt")); err != nil {
+		t.Errorf("hello.txt missing after checking back out %s: %v", branch, err)
+	}
+
+	remote, err := git.PlainOpen(remoteFolder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	remoteHead, err := remote.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remoteHead.Hash().String() != newCommit {
+		t.Errorf("remote HEAD = %s, want %s (Push did not reach the remote)", remoteHead.Hash(), newCommit)
+	}
+}
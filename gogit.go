@@ -1,203 +1,356 @@
-package gogit
-
-import (
-	"bytes"
-	"errors"
-	"io/ioutil"
-	"os"
-	"os/exec"
-	"path"
-	"path/filepath"
-	"strings"
-
-	"github.com/sirupsen/logrus"
-)
-
-// GitRepo is the basic store object.
-type GitRepo struct {
-	repo   string
-	folder string
-	logger *logrus.Logger
-	log    *logrus.Entry
-}
-
-// New returns a new GPGStore that can then needs to be initialized with Init()
-// The `repo` is the supplied git repository. Optionally you can include
-// a string for which folder to save the repo to. The default location is the
-// current directory.
-func New(repo string, optionalFolder ...string) (*GitRepo, error) {
-	var err error
-	gr := new(GitRepo)
-	gr.repo = repo
-	if len(optionalFolder) > 0 {
-		gr.folder = optionalFolder[0]
-	} else {
-		gr.folder = ParseRepoFolder(repo)
-	}
-	gr.folder, err = filepath.Abs(gr.folder)
-	if err != nil {
-		return gr, err
-	}
-	if !exists(gr.folder) {
-		err = os.MkdirAll(gr.folder, 0775)
-		if err != nil {
-			return gr, err
-		}
-	}
-	gr.logger = logrus.New()
-	gr.log = gr.logger.WithFields(logrus.Fields{
-		"source": "gogit",
-	})
-	gr.logger.SetLevel(logrus.WarnLevel)
-	return gr, nil
-}
-
-// Debug will allow verbose output if enabled.
-func (gr *GitRepo) Debug(on bool) {
-	if on {
-		gr.logger.SetLevel(logrus.InfoLevel)
-	} else {
-		gr.logger.SetLevel(logrus.WarnLevel)
-	}
-}
-
-// Update will clone a repo if it doesn't exist or pull a repo, if it does.
-func (gr *GitRepo) Update() (err error) {
-	cwd, err := os.Getwd()
-	if err != nil {
-		return
-	}
-	defer os.Chdir(cwd)
-	err = os.Chdir(gr.folder)
-	if err != nil {
-		return
-	}
-	var cmd *exec.Cmd
-	var stdoutStderr []byte
-	pullOrClone := ""
-	if !exists(path.Join(gr.folder, ".git")) {
-		gr.log.Infof("Running: git clone %s %s", gr.repo, ".")
-		cmd = exec.Command("git", "clone", gr.repo, ".")
-		pullOrClone = "clone"
-	} else {
-		gr.log.Info("Running: git pull --rebase origin master")
-		cmd = exec.Command("git", "pull", "--rebase", "origin", "master")
-		pullOrClone = "pull"
-	}
-	stdoutStderr, err = cmd.CombinedOutput()
-	gr.log.Infof("Output: [%s]\n", stdoutStderr)
-	if bytes.Contains(stdoutStderr, []byte("fatal")) {
-		err = errors.New("Could not " + pullOrClone + " repo")
-	}
-	return
-}
-
-// Push will push the repo to the master branch.
-func (gr *GitRepo) Push() (err error) {
-	cwd, err := os.Getwd()
-	if err != nil {
-		return
-	}
-	defer os.Chdir(cwd)
-	err = os.Chdir(gr.folder)
-	if err != nil {
-		return
-	}
-
-	cmd := exec.Command("git", "push", "origin", "master")
-	gr.log.Info("git push origin master")
-	stdoutStderr, err := cmd.CombinedOutput()
-	gr.log.Infof("Output: [%s]\n", stdoutStderr)
-	if bytes.Contains(stdoutStderr, []byte("error")) {
-		err = errors.New(string(stdoutStderr))
-		return
-	}
-	return
-}
-
-// AddData will write the `data` to a new file, `fp` in the repo
-// and then perform a commit with the message of that files name.
-// Note that the filename should be respective to the root of the
-// repository.
-func (gr *GitRepo) AddData(data []byte, fp string) (err error) {
-	cwd, err := os.Getwd()
-	if err != nil {
-		return
-	}
-	defer os.Chdir(cwd)
-	err = os.Chdir(gr.folder)
-	if err != nil {
-		return
-	}
-	dir, file := filepath.Split(fp)
-	gr.log.Infof("Got file '%s' in path '%s'", file, dir)
-	if len(dir) > 0 {
-		gr.log.Infof("Created directory %s", dir)
-		err = os.MkdirAll(dir, 0775)
-		if err != nil {
-			return
-		}
-	}
-	err = ioutil.WriteFile(fp, data, 0755)
-	if err != nil {
-		return err
-	}
-	gr.log.Infof("Wrote %d bytes", len(data))
-
-	cmd := exec.Command("git", "add", fp)
-	gr.log.Info("git", "add", fp)
-	stdoutStderr, err := cmd.CombinedOutput()
-	gr.log.Infof("Output: [%s]\n", stdoutStderr)
-	if bytes.Contains(stdoutStderr, []byte("error")) {
-		err = errors.New(string(stdoutStderr))
-		return
-	}
-
-	_, fileName := filepath.Split(fp)
-	cmd = exec.Command("git", "commit", "-m", "Add "+fileName, fp)
-	gr.log.Info("git", "commit", "-am", "Add "+fileName, fp)
-	stdoutStderr, _ = cmd.CombinedOutput()
-	gr.log.Infof("Output: [%s]\n", stdoutStderr)
-	if bytes.Contains(stdoutStderr, []byte("error")) {
-		err = errors.New(string(stdoutStderr))
-		return
-	}
-	return
-}
-
-func GetRemoteOriginURL(repoFolder string) (repo string, err error) {
-	// move to repo
-	cwd, err := os.Getwd()
-	if err != nil {
-		return
-	}
-	defer os.Chdir(cwd)
-	err = os.Chdir(repoFolder)
-	if err != nil {
-		return
-	}
-
-	cmd := exec.Command("git", "config", "--get", "remote.origin.url")
-	stdoutStderr, err := cmd.CombinedOutput()
-	repo = strings.TrimSpace(string(stdoutStderr))
-	return
-}
-
-func ParseRepoFolder(repo string) (folder string) {
-	firstPart := strings.Split(repo, ".git")[0]
-	firstPartSplit := strings.Split(firstPart, "/")
-	folder = strings.TrimSpace(firstPartSplit[len(firstPartSplit)-1])
-	return
-}
-
-// exists returns whether the given file or directory exists or not
-func exists(path string) bool {
-	_, err := os.Stat(path)
-	if err == nil {
-		return true
-	}
-	if os.IsNotExist(err) {
-		return false
-	}
-	return true
-}
+package gogit
+
+import (
+	"context"
+	"crypto/rand"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ragodev/gogit/modules/process"
+	"github.com/sirupsen/logrus"
+)
+
+// GitRepo is the basic store object. It is safe for concurrent use: every
+// method that reads or mutates its fields (Update, Push, Checkout,
+// AddData, Log, and the Set* configuration setters) takes mu, and none of
+// them rely on the process-global working directory, so multiple GitRepo
+// instances can run concurrently against different folders without
+// interfering with each other, and a single instance can be driven from
+// multiple goroutines.
+type GitRepo struct {
+	mu            sync.Mutex
+	repo          string
+	folder        string
+	branch        string
+	remote        string
+	identity      *Identity
+	blobStorage   BlobStorage
+	blobThreshold int
+	randomSuffix  bool
+	auth          *Auth
+	ctx           context.Context
+	gitter        Gitter
+	logger        *logrus.Logger
+	log           *logrus.Entry
+}
+
+// Option configures a GitRepo during NewWithOptions.
+type Option func(*GitRepo)
+
+// WithFolder sets the local folder the repo is cloned into. If omitted,
+// NewWithOptions derives one from the repo URL via ParseRepoFolder.
+func WithFolder(folder string) Option {
+	return func(gr *GitRepo) { gr.folder = folder }
+}
+
+// WithBranch sets the branch NewWithOptions checks out on clone (`git
+// clone -b <branch>`). If omitted, the clone uses the remote's default
+// branch. Equivalent to calling SetBranch before the first Update().
+func WithBranch(branch string) Option {
+	return func(gr *GitRepo) { gr.branch = branch }
+}
+
+// WithRandomSuffix appends a short random suffix to the working folder,
+// so multiple GitRepo instances cloning the same remote can coexist on
+// disk instead of colliding on the same directory.
+func WithRandomSuffix() Option {
+	return func(gr *GitRepo) { gr.randomSuffix = true }
+}
+
+// shortID returns a short, filesystem-safe random string suitable for
+// disambiguating working folders.
+func shortID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	const alphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+	for i, v := range b {
+		b[i] = alphabet[int(v)%len(alphabet)]
+	}
+	return string(b)
+}
+
+// New returns a new GitRepo, which is cloned or pulled by calling Update().
+// The `repo` is the supplied git repository. Optionally you can include
+// a string for which folder to save the repo to. The default location is
+// derived from the repo URL via ParseRepoFolder.
+//
+// The returned GitRepo defaults to the pure-Go libGitter backend (no `git`
+// binary required). Call SetGitter to switch to &binaryGitter{} for the
+// previous shell-out behavior. To clone a branch other than the remote's
+// default, call SetBranch before the first Update().
+func New(repo string, optionalFolder ...string) (*GitRepo, error) {
+	gr := &GitRepo{repo: repo}
+	if len(optionalFolder) > 0 {
+		gr.folder = optionalFolder[0]
+	}
+	return initGitRepo(gr)
+}
+
+// NewWithOptions is like New but takes functional Options (WithFolder,
+// WithBranch, WithRandomSuffix) instead of a single positional folder
+// argument, for callers that need to configure more than the folder before
+// the first Update(), e.g. cloning a specific branch in one step.
+func NewWithOptions(repo string, opts ...Option) (*GitRepo, error) {
+	gr := &GitRepo{repo: repo}
+	for _, opt := range opts {
+		opt(gr)
+	}
+	return initGitRepo(gr)
+}
+
+// initGitRepo fills in the defaults shared by New and NewWithOptions once
+// gr.repo and any caller-supplied fields (folder, branch, randomSuffix)
+// are set.
+func initGitRepo(gr *GitRepo) (*GitRepo, error) {
+	var err error
+	if gr.folder == "" {
+		gr.folder = ParseRepoFolder(gr.repo)
+	}
+	if gr.randomSuffix {
+		gr.folder = gr.folder + "-" + shortID()
+	}
+	gr.folder, err = filepath.Abs(gr.folder)
+	if err != nil {
+		return gr, err
+	}
+	if !exists(gr.folder) {
+		err = os.MkdirAll(gr.folder, 0775)
+		if err != nil {
+			return gr, err
+		}
+	}
+	gr.logger = logrus.New()
+	gr.log = gr.logger.WithFields(logrus.Fields{
+		"source": "gogit",
+	})
+	gr.logger.SetLevel(logrus.WarnLevel)
+	gr.ctx = context.Background()
+	gr.gitter = &libGitter{log: gr.log}
+	return gr, nil
+}
+
+// WithContext sets the context used to run subsequent Update/Push/AddData
+// operations, so they can be cancelled or bounded with a deadline. It
+// returns gr for chaining, e.g. gr.WithContext(ctx).Update(). The
+// operation is also registered with process.GetManager(), so it can be
+// listed or killed by ID from outside the call.
+func (gr *GitRepo) WithContext(ctx context.Context) *GitRepo {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+	gr.ctx = ctx
+	return gr
+}
+
+// run registers description with the process manager, deriving a
+// cancellable context from gr.ctx, and passes it to fn.
+func (gr *GitRepo) run(description string, fn func(ctx context.Context) error) error {
+	ctx, finished := process.GetManager().Add(gr.ctx, description)
+	defer finished()
+	ctx = withAuth(ctx, gr.auth)
+	return fn(ctx)
+}
+
+// SetGitter overrides the Gitter backend used for Update/Push/AddData.
+// Use &binaryGitter{} to shell out to the system `git` binary instead of
+// the default pure-Go implementation.
+func (gr *GitRepo) SetGitter(g Gitter) {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+	if bg, ok := g.(*binaryGitter); ok && bg.log == nil {
+		bg.log = gr.log
+	}
+	gr.gitter = g
+}
+
+// SetBranch changes the branch used by Update and Push. If never called,
+// Update records whichever branch the initial clone actually checked out
+// (the remote's default branch, e.g. "main" or "master").
+func (gr *GitRepo) SetBranch(name string) {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+	gr.branch = name
+}
+
+// SetRemote changes the remote used by Update and Push. The default is
+// "origin".
+func (gr *GitRepo) SetRemote(name string) {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+	gr.remote = name
+}
+
+// Checkout switches the working tree to branchOrCommit, which may be
+// either a branch name or a 40-character hex commit SHA.
+func (gr *GitRepo) Checkout(branchOrCommit string) error {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+	gr.log.Infof("checking out %s", branchOrCommit)
+	return gr.run("checkout "+branchOrCommit, func(ctx context.Context) error {
+		return gr.gitter.Checkout(ctx, gr.folder, branchOrCommit)
+	})
+}
+
+// SetIdentity sets the author/committer identity used for commits made
+// by AddData and AddDataWithMessage. Without it, commits fall back to
+// whatever ambient git config (if any) the backend finds.
+func (gr *GitRepo) SetIdentity(name, email string) {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+	gr.identity = &Identity{Name: name, Email: email}
+}
+
+// Log returns the n most recent commits reachable from HEAD, newest
+// first. n <= 0 returns the full history.
+func (gr *GitRepo) Log(n int) (summaries []CommitSummary, err error) {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+	err = gr.run("log", func(ctx context.Context) error {
+		var runErr error
+		summaries, runErr = gr.gitter.Log(ctx, gr.folder, n)
+		return runErr
+	})
+	return
+}
+
+func (gr *GitRepo) effectiveRemote() string {
+	if gr.remote == "" {
+		return "origin"
+	}
+	return gr.remote
+}
+
+// Debug will allow verbose output if enabled.
+func (gr *GitRepo) Debug(on bool) {
+	if on {
+		gr.logger.SetLevel(logrus.InfoLevel)
+	} else {
+		gr.logger.SetLevel(logrus.WarnLevel)
+	}
+}
+
+// Update will clone a repo if it doesn't exist or pull a repo, if it does.
+// On the initial clone, if SetBranch was never called, it records whichever
+// branch the remote actually checked out, so subsequent Update/Push calls
+// target that branch instead of assuming "master".
+func (gr *GitRepo) Update() (err error) {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+	if !exists(path.Join(gr.folder, ".git")) {
+		gr.log.Infof("cloning %s into %s", gr.repo, gr.folder)
+		return gr.run("clone "+gr.repo, func(ctx context.Context) error {
+			resolvedBranch, cloneErr := gr.gitter.Clone(ctx, gr.repo, gr.folder, gr.branch)
+			if cloneErr != nil {
+				return cloneErr
+			}
+			gr.branch = resolvedBranch
+			return nil
+		})
+	}
+	gr.log.Infof("pulling %s %s", gr.effectiveRemote(), gr.branch)
+	return gr.run("pull "+gr.effectiveRemote()+" "+gr.branch, func(ctx context.Context) error {
+		return gr.gitter.Pull(ctx, gr.folder, gr.effectiveRemote(), gr.branch)
+	})
+}
+
+// Push will push the repo to its configured branch, i.e. whatever was
+// passed to SetBranch or, failing that, resolved by the initial Update.
+func (gr *GitRepo) Push() (err error) {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+	gr.log.Infof("pushing %s %s", gr.effectiveRemote(), gr.branch)
+	return gr.run("push "+gr.effectiveRemote()+" "+gr.branch, func(ctx context.Context) error {
+		return gr.gitter.Push(ctx, gr.folder, gr.effectiveRemote(), gr.branch)
+	})
+}
+
+// AddData will write the `data` to a new file, `fp` in the repo
+// and then perform a commit with the message "Add <file name>".
+// Note that the filename should be respective to the root of the
+// repository.
+func (gr *GitRepo) AddData(data []byte, fp string) (err error) {
+	_, err = gr.addData(data, fp, "")
+	return
+}
+
+// AddDataWithMessage is like AddData but takes an explicit commit
+// message and returns the resulting commit SHA.
+func (gr *GitRepo) AddDataWithMessage(data []byte, fp, message string) (commitSHA string, err error) {
+	return gr.addData(data, fp, message)
+}
+
+func (gr *GitRepo) addData(data []byte, fp, message string) (commitSHA string, err error) {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+	fullPath := filepath.Join(gr.folder, fp)
+	dir, file := filepath.Split(fullPath)
+	gr.log.Infof("Got file '%s' in path '%s'", file, dir)
+	if len(dir) > 0 {
+		gr.log.Infof("Created directory %s", dir)
+		err = os.MkdirAll(dir, 0775)
+		if err != nil {
+			return
+		}
+	}
+	toWrite := data
+	if gr.blobStorage != nil && gr.blobThreshold > 0 && len(data) > gr.blobThreshold {
+		toWrite, err = gr.externalizeBlob(data)
+		if err != nil {
+			return "", err
+		}
+	}
+	err = ioutil.WriteFile(fullPath, toWrite, 0755)
+	if err != nil {
+		return "", err
+	}
+	gr.log.Infof("Wrote %d bytes", len(toWrite))
+
+	if message == "" {
+		_, fileName := filepath.Split(fp)
+		message = "Add " + fileName
+	}
+	err = gr.run("commit "+message, func(ctx context.Context) error {
+		if addErr := gr.gitter.Add(ctx, gr.folder, fp); addErr != nil {
+			return addErr
+		}
+		sha, commitErr := gr.gitter.Commit(ctx, gr.folder, message, gr.identity)
+		commitSHA = sha
+		return commitErr
+	})
+	return commitSHA, err
+}
+
+func GetRemoteOriginURL(repoFolder string) (repo string, err error) {
+	cmd := exec.Command("git", "config", "--get", "remote.origin.url")
+	cmd.Dir = repoFolder
+	stdoutStderr, err := cmd.CombinedOutput()
+	repo = strings.TrimSpace(string(stdoutStderr))
+	return
+}
+
+func ParseRepoFolder(repo string) (folder string) {
+	firstPart := strings.Split(repo, ".git")[0]
+	firstPartSplit := strings.Split(firstPart, "/")
+	folder = strings.TrimSpace(firstPartSplit[len(firstPartSplit)-1])
+	return
+}
+
+// exists returns whether the given file or directory exists or not
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	if err == nil {
+		return true
+	}
+	if os.IsNotExist(err) {
+		return false
+	}
+	return true
+}
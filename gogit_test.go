@@ -0,0 +1,206 @@
+package gogit
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// TestNewOptionalFolder verifies that New keeps its original
+// New(repo string, optionalFolder ...string) signature: callers passing a
+// bare folder string, with or without one, must still compile and work.
+func TestNewOptionalFolder(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "gogit-new-optional-folder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	folder := filepath.Join(tmp, "explicit-folder")
+	gr, err := New("https://example.invalid/some/repo.git", folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gr.folder != folder {
+		t.Errorf("folder = %q, want %q", gr.folder, folder)
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(tmp); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(cwd)
+
+	gr, err = New("https://example.invalid/some/repo.git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "repo"; filepath.Base(gr.folder) != want {
+		t.Errorf("derived folder base = %q, want %q", filepath.Base(gr.folder), want)
+	}
+}
+
+// TestConcurrentSameRepo calls AddData, Push, and Update concurrently on a
+// single shared GitRepo instance, to prove gr.mu actually serializes access
+// to that instance rather than just letting distinct instances avoid
+// colliding (see TestConcurrentAddData below for that complementary case).
+func TestConcurrentSameRepo(t *testing.T) {
+	const n = 20
+	tmp, err := ioutil.TempDir("", "gogit-concurrency-single")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	remoteFolder := filepath.Join(tmp, "remote.git")
+	if _, err := git.PlainInit(remoteFolder, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// go-git can't clone an empty remote, so seed it with an initial
+	// commit directly, before handing the clone off to GitRepo.
+	cloneFolder := filepath.Join(tmp, "clone")
+	seed, err := git.PlainInit(cloneFolder, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := seed.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{remoteFolder}}); err != nil {
+		t.Fatal(err)
+	}
+	seedWorktree, err := seed.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(cloneFolder, "seed.txt"), []byte("seed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := seedWorktree.Add("seed.txt"); err != nil {
+		t.Fatal(err)
+	}
+	seedSig := &object.Signature{Name: "Test", Email: "test@example.com"}
+	if _, err := seedWorktree.Commit("seed", &git.CommitOptions{Author: seedSig, Committer: seedSig}); err != nil {
+		t.Fatal(err)
+	}
+	seedHead, err := seed.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := seed.Push(&git.PushOptions{RemoteName: "origin"}); err != nil {
+		t.Fatal(err)
+	}
+
+	gr, err := New(remoteFolder, cloneFolder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gr.SetBranch(seedHead.Name().Short())
+	gr.SetIdentity("Test", "test@example.com")
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			fp := fmt.Sprintf("file-%d.txt", i)
+			if err := gr.AddData([]byte(fmt.Sprintf("data-%d", i)), fp); err != nil {
+				t.Errorf("AddData %d: %v", i, err)
+				return
+			}
+			if err := gr.Push(); err != nil {
+				t.Errorf("Push after AddData %d: %v", i, err)
+				return
+			}
+			if err := gr.Update(); err != nil {
+				t.Errorf("Update after AddData %d: %v", i, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < n; i++ {
+		fp := filepath.Join(cloneFolder, fmt.Sprintf("file-%d.txt", i))
+		got, err := ioutil.ReadFile(fp)
+		if err != nil {
+			t.Fatalf("file %d: %v", i, err)
+		}
+		if want := fmt.Sprintf("data-%d", i); string(got) != want {
+			t.Errorf("file %d: got %q, want %q", i, got, want)
+		}
+	}
+
+	remote, err := git.PlainOpen(remoteFolder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := remote.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, err := remote.CommitObject(head.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	iter := object.NewCommitIterCTime(commit, nil, nil)
+	if err := iter.ForEach(func(*object.Commit) error { count++; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if want := n + 1; count != want { // +1 for the seed commit
+		t.Errorf("remote has %d commits, want %d", count, want)
+	}
+}
+
+func TestConcurrentAddData(t *testing.T) {
+	const n = 50
+	tmp, err := ioutil.TempDir("", "gogit-concurrency")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	repos := make([]*GitRepo, n)
+	for i := 0; i < n; i++ {
+		folder := filepath.Join(tmp, fmt.Sprintf("repo-%d", i))
+		if _, err := git.PlainInit(folder, false); err != nil {
+			t.Fatal(err)
+		}
+		gr, err := New("file://"+folder, folder)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gr.SetIdentity("Test", "test@example.com")
+		repos[i] = gr
+	}
+
+	var wg sync.WaitGroup
+	for i, gr := range repos {
+		wg.Add(1)
+		go func(i int, gr *GitRepo) {
+			defer wg.Done()
+			if err := gr.AddData([]byte(fmt.Sprintf("data-%d", i)), "data.txt"); err != nil {
+				t.Errorf("repo %d: AddData: %v", i, err)
+			}
+		}(i, gr)
+	}
+	wg.Wait()
+
+	for i, gr := range repos {
+		got, err := ioutil.ReadFile(filepath.Join(gr.folder, "data.txt"))
+		if err != nil {
+			t.Fatalf("repo %d: %v", i, err)
+		}
+		if want := fmt.Sprintf("data-%d", i); string(got) != want {
+			t.Errorf("repo %d: got %q, want %q", i, got, want)
+		}
+	}
+}
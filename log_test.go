@@ -0,0 +1,64 @@
+package gogit
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	git "github.com/go-git/go-git/v5"
+)
+
+// TestLog verifies that Log parses go-git's commit history into
+// CommitSummary in newest-first order, and that n bounds the result.
+func TestLog(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "gogit-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	folder := filepath.Join(tmp, "repo")
+	if _, err := git.PlainInit(folder, false); err != nil {
+		t.Fatal(err)
+	}
+	gr, err := New("file://"+folder, folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gr.SetIdentity("Test User", "test@example.com")
+
+	first, err := gr.AddDataWithMessage([]byte("a"), "a.txt", "first commit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := gr.AddDataWithMessage([]byte("b"), "b.txt", "second commit")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	summaries, err := gr.Log(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 2 {
+		t.Fatalf("got %d summaries, want 2", len(summaries))
+	}
+	if summaries[0].Sha != second || summaries[0].Subject != "second commit" {
+		t.Errorf("summaries[0] = %+v, want sha %s subject %q", summaries[0], second, "second commit")
+	}
+	if summaries[1].Sha != first || summaries[1].Subject != "first commit" {
+		t.Errorf("summaries[1] = %+v, want sha %s subject %q", summaries[1], first, "first commit")
+	}
+	if summaries[0].AuthorName != "Test User" || summaries[0].AuthorEmail != "test@example.com" {
+		t.Errorf("summaries[0] identity = %+v, want Test User / test@example.com", summaries[0])
+	}
+
+	limited, err := gr.Log(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(limited) != 1 || limited[0].Sha != second {
+		t.Errorf("Log(1) = %+v, want just %s", limited, second)
+	}
+}
@@ -0,0 +1,89 @@
+// Package process tracks long-running operations (clones, pulls, pushes)
+// so callers can list what's in flight and cancel one that has stalled,
+// e.g. a git pull stuck on a network timeout.
+package process
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Process describes a single tracked operation.
+type Process struct {
+	ID          string
+	Description string
+	Start       time.Time
+	cancel      context.CancelFunc
+}
+
+// Manager tracks in-flight processes, each identified by a unique ID.
+type Manager struct {
+	mu        sync.Mutex
+	counter   int64
+	processes map[string]*Process
+}
+
+// NewManager returns a new, empty Manager.
+func NewManager() *Manager {
+	return &Manager{processes: make(map[string]*Process)}
+}
+
+var defaultManager = NewManager()
+
+// GetManager returns the package-level default Manager.
+func GetManager() *Manager {
+	return defaultManager
+}
+
+// Add registers a new process with description, deriving a cancellable
+// context from parent. The caller must invoke the returned finished func
+// (typically via defer) once the operation completes, to deregister it
+// and release its context.
+func (pm *Manager) Add(parent context.Context, description string) (ctx context.Context, finished func()) {
+	ctx, cancel := context.WithCancel(parent)
+
+	pm.mu.Lock()
+	pm.counter++
+	id := strconv.FormatInt(pm.counter, 10)
+	pm.processes[id] = &Process{
+		ID:          id,
+		Description: description,
+		Start:       time.Now(),
+		cancel:      cancel,
+	}
+	pm.mu.Unlock()
+
+	return ctx, func() {
+		cancel()
+		pm.mu.Lock()
+		delete(pm.processes, id)
+		pm.mu.Unlock()
+	}
+}
+
+// List returns a snapshot of all currently tracked processes.
+func (pm *Manager) List() []*Process {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	list := make([]*Process, 0, len(pm.processes))
+	for _, p := range pm.processes {
+		list = append(list, p)
+	}
+	return list
+}
+
+// Kill cancels the process registered under id, aborting whatever git
+// operation it is running. It reports false if no such process is
+// tracked, e.g. because it already finished.
+func (pm *Manager) Kill(id string) bool {
+	pm.mu.Lock()
+	p, ok := pm.processes[id]
+	pm.mu.Unlock()
+	if !ok {
+		return false
+	}
+	p.cancel()
+	return true
+}
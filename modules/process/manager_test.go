@@ -0,0 +1,70 @@
+package process
+
+import (
+	"context"
+	"testing"
+)
+
+// TestManagerAddListKill registers a process, verifies it shows up in
+// List, kills it by ID, and confirms the context derived by Add is
+// cancelled as a result. It also checks that Kill reports false for an
+// unknown ID.
+func TestManagerAddListKill(t *testing.T) {
+	pm := NewManager()
+
+	ctx, finished := pm.Add(context.Background(), "test operation")
+	defer finished()
+
+	list := pm.List()
+	if len(list) != 1 {
+		t.Fatalf("got %d processes, want 1", len(list))
+	}
+	if list[0].Description != "test operation" {
+		t.Errorf("Description = %q, want %q", list[0].Description, "test operation")
+	}
+	id := list[0].ID
+
+	select {
+	case <-ctx.Done():
+		t.Fatal("context is already cancelled before Kill")
+	default:
+	}
+
+	if ok := pm.Kill(id); !ok {
+		t.Fatalf("Kill(%q) = false, want true", id)
+	}
+
+	select {
+	case <-ctx.Done():
+	default:
+		t.Fatal("context was not cancelled by Kill")
+	}
+
+	if ok := pm.Kill("no-such-id"); ok {
+		t.Errorf("Kill of unknown ID = true, want false")
+	}
+}
+
+// TestManagerFinishedRemovesProcess verifies that calling the finished
+// func returned by Add deregisters the process, so a completed operation
+// no longer shows up in List and can no longer be killed.
+func TestManagerFinishedRemovesProcess(t *testing.T) {
+	pm := NewManager()
+
+	ctx, finished := pm.Add(context.Background(), "short-lived")
+	id := pm.List()[0].ID
+	finished()
+
+	select {
+	case <-ctx.Done():
+	default:
+		t.Fatal("context was not cancelled when finished")
+	}
+
+	if list := pm.List(); len(list) != 0 {
+		t.Errorf("got %d processes after finished, want 0", len(list))
+	}
+	if ok := pm.Kill(id); ok {
+		t.Errorf("Kill of finished process = true, want false")
+	}
+}